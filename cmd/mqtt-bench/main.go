@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	mqttbench "github.com/wm250139/mqtt-bench"
+)
+
+func main() {
+	bench, err := mqttbench.ParseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := bench.Run(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintln(os.Stderr, "Finished run in:", time.Since(start))
+}
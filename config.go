@@ -0,0 +1,157 @@
+package mqttbench
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the on-disk (YAML or JSON) description of a Bench run, as
+// loaded by LoadConfig and the -config flag.
+type Config struct {
+	Times       uint         `json:"times" yaml:"times"`
+	Targets     []Target     `json:"targets" yaml:"targets"`
+	Scenario    Scenario     `json:"scenario" yaml:"scenario"`
+	MetricsAddr string       `json:"metricsAddr" yaml:"metricsAddr"`
+	Output      OutputFormat `json:"output" yaml:"output"`
+	QoS         []byte       `json:"qos" yaml:"qos"`
+}
+
+// LoadConfig reads a Bench configuration from path, parsing it as JSON if
+// the extension is .json and as YAML otherwise.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	if strings.HasSuffix(path, ".json") {
+		err = json.Unmarshal(data, cfg)
+	} else {
+		err = yaml.Unmarshal(data, cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// targetFlag implements flag.Value for the repeatable -target flag, whose
+// value looks like "name=local,pub=host:1883,sub=host:1883,transport=tls".
+type targetFlag struct {
+	targets *[]Target
+}
+
+func (f targetFlag) String() string { return "" }
+
+func (f targetFlag) Set(value string) error {
+	t := Target{}
+	for _, field := range strings.Split(value, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid -target field %q, want key=value", field)
+		}
+
+		switch kv[0] {
+		case "name":
+			t.Name = kv[1]
+		case "pub":
+			t.PubAddr = kv[1]
+		case "sub":
+			t.SubAddr = kv[1]
+		case "transport":
+			t.Transport = Transport(kv[1])
+		default:
+			return fmt.Errorf("unknown -target field %q", kv[0])
+		}
+	}
+
+	*f.targets = append(*f.targets, t)
+	return nil
+}
+
+// ParseFlags builds a Bench from CLI flags. If -config is given it is
+// loaded first as the base configuration; any other flags passed override
+// the corresponding field.
+func ParseFlags(args []string) (*Bench, error) {
+	fs := flag.NewFlagSet("mqtt-bench", flag.ContinueOnError)
+
+	configPath := fs.String("config", "", "path to a YAML or JSON config file describing targets and scenario")
+	times := fs.Uint("times", 0, "messages per publisher (shorthand for a single-publisher scenario)")
+	topic := fs.String("topic", "", "topic to publish/subscribe on")
+	payloadSize := fs.Int("payload-size", 0, "payload size in bytes")
+	rate := fs.Float64("rate", 0, "messages/sec per publisher")
+	output := fs.String("output", "", "output format: table, json, or csv")
+	metricsAddr := fs.String("metrics-addr", "", "address to serve live Prometheus metrics on, e.g. :9090")
+	qos := fs.Int("qos", -1, "restrict the run to a single QoS level (0, 1, or 2); defaults to running all three")
+
+	var targets []Target
+	fs.Var(targetFlag{targets: &targets}, "target", "target in the form name=...,pub=host:port,sub=host:port,transport=tcp|tls|ws|wss|quic (repeatable)")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	bench := &Bench{}
+	if *configPath != "" {
+		cfg, err := LoadConfig(*configPath)
+		if err != nil {
+			return nil, err
+		}
+		bench.Times = cfg.Times
+		bench.Targets = cfg.Targets
+		bench.Scenario = cfg.Scenario
+		bench.MetricsAddr = cfg.MetricsAddr
+		bench.Output = cfg.Output
+		bench.QoS = cfg.QoS
+	}
+
+	if len(targets) > 0 {
+		bench.Targets = targets
+	}
+	if *times > 0 {
+		bench.Times = *times
+	}
+
+	// Resolve the scenario to its DefaultScenario(bench.Times) form before
+	// applying -topic/-payload-size/-rate below, so those flags land on a
+	// scenario with Messages already populated instead of being silently
+	// discarded by Bench.scenario()'s own zero-value check.
+	if bench.Scenario.Publishers == 0 && bench.Scenario.Messages == 0 && bench.Scenario.Duration == 0 {
+		bench.Scenario = DefaultScenario(bench.Times)
+	}
+
+	if *topic != "" {
+		bench.Scenario.Topics = []string{*topic}
+	}
+	if *payloadSize > 0 {
+		bench.Scenario.PayloadSize = *payloadSize
+	}
+	if *rate > 0 {
+		bench.Scenario.Rate = *rate
+	}
+	if *output != "" {
+		bench.Output = OutputFormat(*output)
+	}
+	if *metricsAddr != "" {
+		bench.MetricsAddr = *metricsAddr
+	}
+	if *qos >= 0 {
+		if *qos > 2 {
+			return nil, fmt.Errorf("invalid -qos %d: must be 0, 1, or 2", *qos)
+		}
+		bench.QoS = []byte{byte(*qos)}
+	}
+
+	if len(bench.Targets) == 0 {
+		return nil, fmt.Errorf("no targets configured: pass -target or -config")
+	}
+
+	return bench, nil
+}
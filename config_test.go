@@ -0,0 +1,53 @@
+package mqttbench
+
+import "testing"
+
+// TestParseFlagsKeepsScenarioOverrides is a regression test for a bug where
+// a CLI-only run's -rate/-topic/-payload-size were silently discarded:
+// Bench.scenario() falls back to DefaultScenario whenever
+// Publishers/Messages/Duration are all zero, which was true of every
+// CLI-only run since those flags never set them.
+func TestParseFlagsKeepsScenarioOverrides(t *testing.T) {
+	bench, err := ParseFlags([]string{
+		"-target", "name=t,pub=localhost:1883,sub=localhost:1883",
+		"-times", "100",
+		"-rate", "250",
+		"-topic", "bench/custom",
+		"-payload-size", "128",
+	})
+	if err != nil {
+		t.Fatalf("ParseFlags: %v", err)
+	}
+
+	if bench.Scenario.Rate != 250 {
+		t.Errorf("Scenario.Rate = %v, want 250", bench.Scenario.Rate)
+	}
+	if len(bench.Scenario.Topics) != 1 || bench.Scenario.Topics[0] != "bench/custom" {
+		t.Errorf("Scenario.Topics = %v, want [bench/custom]", bench.Scenario.Topics)
+	}
+	if bench.Scenario.PayloadSize != 128 {
+		t.Errorf("Scenario.PayloadSize = %v, want 128", bench.Scenario.PayloadSize)
+	}
+	if bench.Scenario.Messages != 100 {
+		t.Errorf("Scenario.Messages = %v, want 100 (from -times)", bench.Scenario.Messages)
+	}
+}
+
+func TestParseFlagsQoS(t *testing.T) {
+	bench, err := ParseFlags([]string{
+		"-target", "name=t,pub=localhost:1883,sub=localhost:1883",
+		"-qos", "1",
+	})
+	if err != nil {
+		t.Fatalf("ParseFlags: %v", err)
+	}
+	if len(bench.QoS) != 1 || bench.QoS[0] != 1 {
+		t.Errorf("QoS = %v, want [1]", bench.QoS)
+	}
+}
+
+func TestParseFlagsNoTargets(t *testing.T) {
+	if _, err := ParseFlags(nil); err == nil {
+		t.Error("ParseFlags with no -target/-config: want error, got nil")
+	}
+}
@@ -1,67 +1,33 @@
-package main
+package mqttbench
 
 import (
 	"context"
 	"fmt"
 	"github.com/eclipse/paho.golang/paho"
 	"github.com/montanaflynn/stats"
-	"github.com/olekukonko/tablewriter"
-	"log"
-	"net"
+	"github.com/prometheus/client_golang/prometheus"
 	"os"
 	"regexp"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-func main() {
-	bench := &Bench{
-		Times: 1000,
-		Targets: []Target{
-			{
-				// Measure connections over the loopback device
-				Name:    "local",
-				PubAddr: "localhost:1883",
-				SubAddr: "localhost:1883",
-			},
-			{
-				// Measure connections to a local VM
-				// NB: This isn't measuring much, but was good to see the difference between loopback devices (above)
-				//     and local but distinct devices.
-				Name:    "local vm",
-				PubAddr: "vm-ip:1883",
-				SubAddr: "vm-ip:1883",
-			},
-			{
-				// Measure connections to a remote (cloud-based) broker
-				Name:    "remote vm",
-				PubAddr: "remote.net:1883",
-				SubAddr: "remote.net:1883",
-			},
-			{
-				// Measure relying on HiveMQ's clustering to get messages from producer (local) to consumer (remote)
-				// NB: Requires HiveMQ cluster set up beforehand
-				Name:    "two-node cluster (local + remote)",
-				PubAddr: "localhost:1884",
-				SubAddr: "remote.net:1884",
-			},
-		},
-	}
-
-	start := time.Now()
-	if err := bench.Run(); err != nil {
-		log.Fatal(err)
-	}
-	fmt.Println("Finished run in:", time.Since(start))
-}
-
 type Target struct {
 	Name string
 
 	PubAddr string
 	SubAddr string
 
+	// Transport selects the network transport used for both PubAddr and
+	// SubAddr. Defaults to TransportTCP.
+	Transport Transport
+
+	// TransportOptions configures TLS/WS/QUIC specifics. Ignored for the
+	// default tcp transport.
+	TransportOptions TransportOptions
+
 	pub *paho.Client
 	sub *paho.Client
 }
@@ -71,13 +37,13 @@ func (t *Target) init() error {
 		return nil
 	}
 
-	pub, err := newClient(fmt.Sprintf("%s-pub", t.Name), t.PubAddr)
+	pub, err := newClient(fmt.Sprintf("%s-pub", t.Name), t.PubAddr, t.Transport, t.TransportOptions)
 	if err != nil {
 		return err
 	}
 	t.pub = pub
 
-	sub, err := newClient(fmt.Sprintf("%s-sub", t.Name), t.SubAddr)
+	sub, err := newClient(fmt.Sprintf("%s-sub", t.Name), t.SubAddr, t.Transport, t.TransportOptions)
 	if err != nil {
 		return err
 	}
@@ -102,8 +68,8 @@ func (t *Target) Close() error {
 	return nil
 }
 
-func newClient(name string, addr string) (*paho.Client, error) {
-	conn, err := net.Dial("tcp", addr)
+func newClient(name string, addr string, transport Transport, opts TransportOptions) (*paho.Client, error) {
+	conn, err := dial(transport, addr, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -125,122 +91,261 @@ func newClient(name string, addr string) (*paho.Client, error) {
 type Bench struct {
 	Times   uint
 	Targets []Target
+
+	// Scenario, when set, overrides the single-publisher/fixed-rate
+	// behavior implied by Times. Leave zero-value to keep the original
+	// behavior.
+	Scenario Scenario
+
+	// MetricsAddr, when set, serves live Prometheus metrics for the run
+	// on this address (e.g. ":9090") in addition to the final table.
+	MetricsAddr string
+
+	// Recorder, when set, additionally receives every latency/throughput
+	// event as the run progresses (e.g. a PrometheusRecorder). The final
+	// table is always built from runTarget's own latencyTracker,
+	// regardless of this setting.
+	Recorder Recorder
+
+	// Output selects how results are rendered once the run completes.
+	// Defaults to OutputTable.
+	Output OutputFormat
+
+	// QoS restricts Run to the given QoS levels. Leave nil to run all of
+	// QoS 0, 1, and 2, as before.
+	QoS []byte
+}
+
+func (b *Bench) qosLevels() []byte {
+	if len(b.QoS) > 0 {
+		return b.QoS
+	}
+	return []byte{0, 1, 2}
+}
+
+func (b *Bench) scenario() Scenario {
+	if b.Scenario.Publishers == 0 && b.Scenario.Messages == 0 && b.Scenario.Duration == 0 {
+		return DefaultScenario(b.Times)
+	}
+	return b.Scenario
 }
 
 func (b *Bench) Run() error {
-	fmt.Printf("Number of messages: %d\n", b.Times)
-
-	tw := tablewriter.NewWriter(os.Stdout)
-	tw.SetAutoWrapText(false)
-	tw.SetAutoFormatHeaders(false)
-	tw.SetAutoMergeCellsByColumnIndex([]int{0})
-	tw.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
-	tw.SetCenterSeparator("|")
-	tw.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
-	tw.SetHeader([]string{"Configuration", "QoS", "Min", "Max", "Avg", "P95", "P99"})
-	defer tw.Render()
+	scenario := b.scenario()
+	fmt.Fprintf(os.Stderr, "Number of publishers: %d, messages per publisher: %d\n", maxInt(scenario.Publishers, 1), messagesPerPublisher(scenario))
+
+	recorders := MultiRecorder{NewMemoryRecorder()}
+	if b.Recorder != nil {
+		recorders = append(recorders, b.Recorder)
+	}
+
+	if b.MetricsAddr != "" {
+		registry := prometheus.NewRegistry()
+		recorders = append(recorders, NewPrometheusRecorder(registry))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			if err := ServeMetrics(ctx, b.MetricsAddr, registry); err != nil {
+				fmt.Fprintln(os.Stderr, "metrics server error:", err)
+			}
+		}()
+	}
+
+	var rows []resultRow
 
 	for _, t := range b.Targets {
 		if err := t.init(); err != nil {
 			return err
 		}
 
-		r0, err := runTarget(t, b.Times, 0)
-		if err != nil {
-			return err
-		}
-		tw.Append([]string{t.Name, "0", durStr(r0.Min), durStr(r0.Max), durStr(r0.Avg), durStr(r0.P95), durStr(r0.P99)})
+		for _, qos := range b.qosLevels() {
+			r, err := runTarget(t, scenario, qos, recorders)
+			if err != nil {
+				return err
+			}
 
-		r1, err := runTarget(t, b.Times, 1)
-		if err != nil {
-			return err
+			rows = append(rows, newResultRow(t.Name, qos, r))
 		}
-		tw.Append([]string{t.Name, "1", durStr(r1.Min), durStr(r1.Max), durStr(r1.Avg), durStr(r1.P95), durStr(r1.P99)})
 
 		if err := t.Close(); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return render(os.Stdout, b.Output, rows)
 }
 
-func runTarget(target Target, times uint, qos byte) (*Result, error) {
-	var wg sync.WaitGroup
-	wg.Add(int(times))
-	var durations []time.Duration
+func runTarget(target Target, scenario Scenario, qos byte, recorder Recorder) (*Result, error) {
+	publishers := maxInt(scenario.Publishers, 1)
+	expectedTotal := uint64(publishers) * uint64(messagesPerPublisher(scenario))
 
-	target.sub.Router = paho.NewSingleHandlerRouter(func(p *paho.Publish) {
-		defer wg.Done()
+	tracker := newLatencyTracker()
+	ackTracker := newLatencyTracker()
+	var receivedCount uint64
 
-		t, err := strconv.ParseInt(string(p.Payload), 10, 64)
+	done := make(chan struct{})
+
+	subs := map[string]paho.SubscribeOptions{}
+	for pubID := 0; pubID < publishers; pubID++ {
+		for _, topic := range expandTopics(scenarioTopics(scenario), pubID) {
+			subs[topic] = paho.SubscribeOptions{QoS: qos}
+		}
+	}
+
+	target.sub.Router = paho.NewSingleHandlerRouter(func(p *paho.Publish) {
+		publisherID, _, sentAt, err := parsePayload(p.Payload)
 		if err != nil {
-			fmt.Println("Unable to parse int:", err)
+			fmt.Println("Unable to parse payload:", err)
 			return
 		}
 
-		now := time.Now().UnixNano()
-		dur := now - t
-		durations = append(durations, time.Duration(dur))
+		dur := time.Since(sentAt)
+		tracker.record(publisherID, dur)
+		recorder.RecordLatency(target.Name, qos, dur)
+		recorder.RecordReceived(target.Name, qos)
+
+		if expectedTotal > 0 && atomic.AddUint64(&receivedCount, 1) == expectedTotal {
+			close(done)
+		}
 	})
 
-	if _, err := target.sub.Subscribe(context.Background(), &paho.Subscribe{Subscriptions: map[string]paho.SubscribeOptions{
-		"scox/bench": {QoS: qos},
-	}}); err != nil {
+	if _, err := target.sub.Subscribe(context.Background(), &paho.Subscribe{Subscriptions: subs}); err != nil {
 		_ = target.Close()
 		return nil, err
 	}
 
-	//defer target.sub.Unsubscribe(context.Background(), &paho.Unsubscribe{Topics: []string{"scox/bench"}})
-
-	for i := uint(0); i < times; i++ {
-		now := time.Now().UnixNano()
-		if _, err := target.pub.Publish(context.Background(), &paho.Publish{
-			Topic:   "scox/bench",
-			QoS:     qos,
-			Payload: []byte(fmt.Sprint(now)),
-		}); err != nil {
-			_ = target.Close()
-			return nil, err
+	// Unsubscribe (and wait for the broker to ack it) before returning, so a
+	// message still in flight from this QoS pass's loss-timeout can't arrive
+	// after the next QoS pass has already reassigned target.sub.Router and
+	// started counting toward its own receivedCount.
+	defer func() {
+		topics := make([]string, 0, len(subs))
+		for topic := range subs {
+			topics = append(topics, topic)
+		}
+		if _, err := target.sub.Unsubscribe(context.Background(), &paho.Unsubscribe{Topics: topics}); err != nil {
+			fmt.Println("unsubscribe error:", err)
+		}
+	}()
+
+	var pubWg sync.WaitGroup
+	for pubID := 0; pubID < publishers; pubID++ {
+		pubID := pubID
+		pubWg.Add(1)
+		go func() {
+			defer pubWg.Done()
+			if err := runPublisher(target, scenario, qos, uint32(pubID), recorder, ackTracker); err != nil {
+				fmt.Println("publisher", pubID, "error:", err)
+				recorder.RecordError(target.Name, qos, err)
+			}
+		}()
+	}
+	pubWg.Wait()
+
+	// Rather than blocking forever on a sync.WaitGroup, give outstanding
+	// messages LossTimeout to arrive, then move on and report whatever
+	// didn't as loss (relevant at QoS 0, which has no delivery guarantee).
+	if expectedTotal > 0 {
+		select {
+		case <-done:
+		case <-time.After(lossTimeout(scenario)):
 		}
-		time.Sleep(10 * time.Millisecond)
 	}
-
-	wg.Wait()
 
 	fmt.Printf("%s: (pub: %s, sub: %s, QoS: %d)\n", target.Name, target.PubAddr, target.SubAddr, qos)
 
-	var min time.Duration = -1
-	var max time.Duration = -1
-	var sum time.Duration
+	min, max, avg, count, samples := tracker.merge()
 
-	for _, dur := range durations {
-		if min == -1 || dur < min {
-			min = dur
+	fmt.Printf("  min: %s\n", durStr(min))
+	fmt.Printf("  max: %s\n", durStr(max))
+	fmt.Printf("  avg: %s\n", durStr(avg))
+
+	var p95, p99 time.Duration
+	var err error
+	if count > 0 {
+		p95, err = percentile(samples, 95.0)
+		if err != nil {
+			return nil, err
 		}
-		if max == -1 || dur > max {
-			max = dur
+		p99, err = percentile(samples, 99.0)
+		if err != nil {
+			return nil, err
 		}
-		sum += dur
 	}
 
-	var avg time.Duration = sum / time.Duration(len(durations))
+	var ackP95 time.Duration
+	if _, _, _, ackCount, ackSamples := ackTracker.merge(); ackCount > 0 {
+		ackP95, err = percentile(ackSamples, 95.0)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	fmt.Printf("  min: %s\n", durStr(min))
-	fmt.Printf("  max: %s\n", durStr(max))
-	fmt.Printf("  avg: %s\n", durStr(avg))
+	var lossRate float64
+	if expectedTotal > 0 {
+		lossRate = 1 - float64(count)/float64(expectedTotal)
+	}
 
-	// Calculate percentiles
-	p95, err := percentile(durations, 95.0)
-	if err != nil {
-		return nil, err
+	return &Result{Min: min, Max: max, Avg: avg, P95: p95, P99: p99, AckP95: ackP95, LossRate: lossRate}, nil
+}
+
+// runPublisher sends messagesPerPublisher(scenario) messages to the topics
+// assigned to publisherID, spaced according to the scenario's rate/jitter,
+// recording the time spent in the broker acknowledgment handshake for each
+// message (PUBACK round-trip for QoS1, PUBREC/PUBREL/PUBCOMP round-trip
+// for QoS2; not recorded for QoS0, which has no ack) into ackTracker. When
+// scenario.ClientsPerPublisher is set, it opens that many of its own
+// connections and round-robins messages across them instead of sharing
+// target.pub.
+func runPublisher(target Target, scenario Scenario, qos byte, publisherID uint32, recorder Recorder, ackTracker *latencyTracker) error {
+	clients := []*paho.Client{target.pub}
+	if n := scenario.ClientsPerPublisher; n > 0 {
+		clients = make([]*paho.Client, n)
+		for i := 0; i < n; i++ {
+			c, err := newClient(fmt.Sprintf("%s-pub-%d-%d", target.Name, publisherID, i), target.PubAddr, target.Transport, target.TransportOptions)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = c.Disconnect(&paho.Disconnect{}) }()
+			clients[i] = c
+		}
 	}
-	p99, err := percentile(durations, 99.0)
-	if err != nil {
-		return nil, err
+
+	topics := expandTopics(scenarioTopics(scenario), int(publisherID))
+	messages := messagesPerPublisher(scenario)
+
+	for seq := uint32(0); seq < uint32(messages); seq++ {
+		topic := topics[int(seq)%len(topics)]
+		client := clients[int(seq)%len(clients)]
+
+		ackStart := time.Now()
+		if _, err := client.Publish(context.Background(), &paho.Publish{
+			Topic:   topic,
+			QoS:     qos,
+			Payload: buildPayload(publisherID, seq, scenario.PayloadSize),
+		}); err != nil {
+			return err
+		}
+		recorder.RecordSent(target.Name, qos)
+		if qos > 0 {
+			// paho.Client.Publish blocks until the ack handshake for the
+			// message completes, so the elapsed time here is the broker's
+			// ack/handshake latency rather than end-to-end delivery time.
+			ackTracker.record(publisherID, time.Since(ackStart))
+		}
+
+		time.Sleep(interMessageDelay(scenario))
 	}
 
-	return &Result{Min: min, Max: max, Avg: avg, P95: p95, P99: p99}, nil
+	return nil
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
 }
 
 type Result struct {
@@ -249,6 +354,15 @@ type Result struct {
 	Avg time.Duration
 	P95 time.Duration
 	P99 time.Duration
+
+	// AckP95 is the P95 broker ack/handshake latency (PUBACK for QoS1,
+	// PUBREC/PUBREL/PUBCOMP for QoS2), zero for QoS0 which has no ack.
+	AckP95 time.Duration
+
+	// LossRate is the fraction of published messages never received
+	// within the scenario's LossTimeout, most relevant at QoS 0 which has
+	// no delivery guarantee.
+	LossRate float64
 }
 
 func durStr(dur time.Duration) string {
@@ -0,0 +1,162 @@
+package mqttbench
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Recorder receives events from a running Bench as they happen.
+// Implementations: MemoryRecorder, PrometheusRecorder.
+type Recorder interface {
+	RecordLatency(target string, qos byte, dur time.Duration)
+	RecordSent(target string, qos byte)
+	RecordReceived(target string, qos byte)
+	RecordError(target string, qos byte, err error)
+}
+
+type recorderKey struct {
+	target string
+	qos    byte
+}
+
+// MemoryRecorder tracks sent/received/error counts in memory. Latency is
+// tracked by runTarget's latencyTracker instead.
+type MemoryRecorder struct {
+	mu       sync.Mutex
+	sent     map[recorderKey]int
+	received map[recorderKey]int
+	errors   map[recorderKey]int
+}
+
+func NewMemoryRecorder() *MemoryRecorder {
+	return &MemoryRecorder{
+		sent:     map[recorderKey]int{},
+		received: map[recorderKey]int{},
+		errors:   map[recorderKey]int{},
+	}
+}
+
+func (r *MemoryRecorder) RecordLatency(target string, qos byte, dur time.Duration) {}
+
+func (r *MemoryRecorder) RecordSent(target string, qos byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent[recorderKey{target, qos}]++
+}
+
+func (r *MemoryRecorder) RecordReceived(target string, qos byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.received[recorderKey{target, qos}]++
+}
+
+func (r *MemoryRecorder) RecordError(target string, qos byte, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors[recorderKey{target, qos}]++
+}
+
+// PrometheusRecorder records latency/throughput as Prometheus metrics,
+// served over a /metrics HTTP endpoint by ServeMetrics.
+type PrometheusRecorder struct {
+	latency  *prometheus.HistogramVec
+	sent     *prometheus.CounterVec
+	received *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+}
+
+func NewPrometheusRecorder(registry *prometheus.Registry) *PrometheusRecorder {
+	r := &PrometheusRecorder{
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "mqtt_bench_latency_seconds",
+			Help:    "End-to-end publish-to-receive latency.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"target", "qos"}),
+		sent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mqtt_bench_messages_sent_total",
+			Help: "Messages published.",
+		}, []string{"target", "qos"}),
+		received: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mqtt_bench_messages_received_total",
+			Help: "Messages received.",
+		}, []string{"target", "qos"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mqtt_bench_errors_total",
+			Help: "Publish/subscribe errors.",
+		}, []string{"target", "qos"}),
+	}
+
+	registry.MustRegister(r.latency, r.sent, r.received, r.errors)
+	return r
+}
+
+func (r *PrometheusRecorder) RecordLatency(target string, qos byte, dur time.Duration) {
+	r.latency.WithLabelValues(target, qosLabel(qos)).Observe(dur.Seconds())
+}
+
+func (r *PrometheusRecorder) RecordSent(target string, qos byte) {
+	r.sent.WithLabelValues(target, qosLabel(qos)).Inc()
+}
+
+func (r *PrometheusRecorder) RecordReceived(target string, qos byte) {
+	r.received.WithLabelValues(target, qosLabel(qos)).Inc()
+}
+
+func (r *PrometheusRecorder) RecordError(target string, qos byte, err error) {
+	r.errors.WithLabelValues(target, qosLabel(qos)).Inc()
+}
+
+func qosLabel(qos byte) string {
+	return strconv.Itoa(int(qos))
+}
+
+// ServeMetrics starts an HTTP server exposing registry in Prometheus text
+// format on addr, running until ctx is canceled.
+func ServeMetrics(ctx context.Context, addr string, registry *prometheus.Registry) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// MultiRecorder fans a single event out to several Recorders.
+type MultiRecorder []Recorder
+
+func (m MultiRecorder) RecordLatency(target string, qos byte, dur time.Duration) {
+	for _, r := range m {
+		r.RecordLatency(target, qos, dur)
+	}
+}
+
+func (m MultiRecorder) RecordSent(target string, qos byte) {
+	for _, r := range m {
+		r.RecordSent(target, qos)
+	}
+}
+
+func (m MultiRecorder) RecordReceived(target string, qos byte) {
+	for _, r := range m {
+		r.RecordReceived(target, qos)
+	}
+}
+
+func (m MultiRecorder) RecordError(target string, qos byte, err error) {
+	for _, r := range m {
+		r.RecordError(target, qos, err)
+	}
+}
@@ -0,0 +1,84 @@
+package mqttbench
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestQoSLabel(t *testing.T) {
+	for qos, want := range map[byte]string{0: "0", 1: "1", 2: "2"} {
+		if got := qosLabel(qos); got != want {
+			t.Errorf("qosLabel(%d) = %q, want %q", qos, got, want)
+		}
+	}
+}
+
+// fakeRecorder counts how many times each method was called, so
+// TestMultiRecorderFansOut can check every constituent Recorder was reached.
+type fakeRecorder struct {
+	latency, sent, received, errs int
+}
+
+func (f *fakeRecorder) RecordLatency(target string, qos byte, dur time.Duration) { f.latency++ }
+func (f *fakeRecorder) RecordSent(target string, qos byte)                       { f.sent++ }
+func (f *fakeRecorder) RecordReceived(target string, qos byte)                   { f.received++ }
+func (f *fakeRecorder) RecordError(target string, qos byte, err error)           { f.errs++ }
+
+func TestMultiRecorderFansOut(t *testing.T) {
+	a, b := &fakeRecorder{}, &fakeRecorder{}
+	m := MultiRecorder{a, b}
+
+	m.RecordLatency("t", 1, time.Millisecond)
+	m.RecordSent("t", 1)
+	m.RecordReceived("t", 1)
+	m.RecordError("t", 1, errors.New("boom"))
+
+	for name, r := range map[string]*fakeRecorder{"a": a, "b": b} {
+		if r.latency != 1 || r.sent != 1 || r.received != 1 || r.errs != 1 {
+			t.Errorf("%s = %+v, want all counts 1", name, r)
+		}
+	}
+}
+
+func TestPrometheusRecorderRegistersAndLabels(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	r := NewPrometheusRecorder(registry)
+
+	r.RecordSent("broker-a", 1)
+	r.RecordSent("broker-a", 1)
+	r.RecordReceived("broker-a", 1)
+	r.RecordError("broker-b", 0, errors.New("boom"))
+	r.RecordLatency("broker-a", 1, 5*time.Millisecond)
+
+	if got := testutil.ToFloat64(r.sent.WithLabelValues("broker-a", "1")); got != 2 {
+		t.Errorf("sent{broker-a,1} = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(r.received.WithLabelValues("broker-a", "1")); got != 1 {
+		t.Errorf("received{broker-a,1} = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(r.errors.WithLabelValues("broker-b", "0")); got != 1 {
+		t.Errorf("errors{broker-b,0} = %v, want 1", got)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+
+	var sawHistogram bool
+	for _, mf := range families {
+		if mf.GetName() == "mqtt_bench_latency_seconds" {
+			sawHistogram = true
+			if got := mf.GetMetric()[0].GetHistogram().GetSampleCount(); got != 1 {
+				t.Errorf("latency sample count = %d, want 1", got)
+			}
+		}
+	}
+	if !sawHistogram {
+		t.Error("mqtt_bench_latency_seconds not registered")
+	}
+}
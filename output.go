@@ -0,0 +1,121 @@
+package mqttbench
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// OutputFormat selects how Bench.Run renders its results.
+type OutputFormat string
+
+const (
+	OutputTable OutputFormat = "table"
+	OutputJSON  OutputFormat = "json"
+	OutputCSV   OutputFormat = "csv"
+)
+
+// resultRow is one target+QoS combination's summary statistics, shaped for
+// the table/json/csv formatters.
+type resultRow struct {
+	Target       string `json:"target"`
+	QoS          byte   `json:"qos"`
+	Min          string `json:"min"`
+	Max          string `json:"max"`
+	Avg          string `json:"avg"`
+	P95          string `json:"p95"`
+	P99          string `json:"p99"`
+	PubAckP95    string `json:"pub_ack_p95,omitempty"`
+	HandshakeP95 string `json:"handshake_p95,omitempty"`
+	Loss         string `json:"loss"`
+}
+
+func newResultRow(target string, qos byte, r *Result) resultRow {
+	row := resultRow{
+		Target: target,
+		QoS:    qos,
+		Min:    durStr(r.Min),
+		Max:    durStr(r.Max),
+		Avg:    durStr(r.Avg),
+		P95:    durStr(r.P95),
+		P99:    durStr(r.P99),
+		Loss:   fmt.Sprintf("%.2f%%", r.LossRate*100),
+	}
+
+	switch qos {
+	case 1:
+		row.PubAckP95 = durStr(r.AckP95)
+	case 2:
+		row.HandshakeP95 = durStr(r.AckP95)
+	}
+
+	return row
+}
+
+// render writes rows to w in the given format, defaulting to OutputTable.
+func render(w io.Writer, format OutputFormat, rows []resultRow) error {
+	switch format {
+	case "", OutputTable:
+		return renderTable(w, rows)
+	case OutputJSON:
+		return renderJSON(w, rows)
+	case OutputCSV:
+		return renderCSV(w, rows)
+	default:
+		return fmt.Errorf("unknown output format: %q", format)
+	}
+}
+
+func renderTable(w io.Writer, rows []resultRow) error {
+	tw := tablewriter.NewWriter(w)
+	tw.SetAutoWrapText(false)
+	tw.SetAutoFormatHeaders(false)
+	tw.SetAutoMergeCellsByColumnIndex([]int{0})
+	tw.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	tw.SetCenterSeparator("|")
+	tw.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	tw.SetHeader([]string{"Configuration", "QoS", "Min", "Max", "Avg", "P95", "P99", "PubAck P95", "Handshake P95", "Loss"})
+
+	for _, row := range rows {
+		pubAck, handshake := row.PubAckP95, row.HandshakeP95
+		if pubAck == "" {
+			pubAck = "-"
+		}
+		if handshake == "" {
+			handshake = "-"
+		}
+		tw.Append([]string{row.Target, strconv.Itoa(int(row.QoS)), row.Min, row.Max, row.Avg, row.P95, row.P99, pubAck, handshake, row.Loss})
+	}
+
+	tw.Render()
+	return nil
+}
+
+func renderJSON(w io.Writer, rows []resultRow) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+func renderCSV(w io.Writer, rows []resultRow) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	if err := cw.Write([]string{"target", "qos", "min", "max", "avg", "p95", "p99", "pub_ack_p95", "handshake_p95", "loss"}); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if err := cw.Write([]string{
+			row.Target, strconv.Itoa(int(row.QoS)), row.Min, row.Max, row.Avg, row.P95, row.P99, row.PubAckP95, row.HandshakeP95, row.Loss,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return cw.Error()
+}
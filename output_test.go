@@ -0,0 +1,58 @@
+package mqttbench
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRenderJSON(t *testing.T) {
+	rows := []resultRow{newResultRow("local", 1, &Result{Min: time.Millisecond, AckP95: 2 * time.Millisecond})}
+
+	var buf bytes.Buffer
+	if err := render(&buf, OutputJSON, rows); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	var got []resultRow
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal rendered JSON: %v", err)
+	}
+	if len(got) != 1 || got[0].Target != "local" || got[0].PubAckP95 == "" {
+		t.Errorf("render(JSON) round-tripped to %+v", got)
+	}
+}
+
+func TestRenderCSV(t *testing.T) {
+	rows := []resultRow{newResultRow("local", 0, &Result{Min: time.Millisecond, LossRate: 0.5})}
+
+	var buf bytes.Buffer
+	if err := render(&buf, OutputCSV, rows); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("parse rendered CSV: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("len(records) = %d, want 2 (header + 1 row)", len(records))
+	}
+	if records[1][0] != "local" || records[1][9] != "50.00%" {
+		t.Errorf("csv row = %v", records[1])
+	}
+}
+
+func TestNewResultRowQoSColumns(t *testing.T) {
+	qos1 := newResultRow("t", 1, &Result{AckP95: time.Millisecond})
+	if qos1.PubAckP95 == "" || qos1.HandshakeP95 != "" {
+		t.Errorf("QoS1 row = %+v, want PubAckP95 set and HandshakeP95 empty", qos1)
+	}
+
+	qos2 := newResultRow("t", 2, &Result{AckP95: time.Millisecond})
+	if qos2.HandshakeP95 == "" || qos2.PubAckP95 != "" {
+		t.Errorf("QoS2 row = %+v, want HandshakeP95 set and PubAckP95 empty", qos2)
+	}
+}
@@ -0,0 +1,120 @@
+package mqttbench
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// ringShardCount bounds how many latencyShards a latencyTracker uses. A
+// message's publisher id picks its shard.
+const ringShardCount = 16
+
+// ringShardSize bounds how many raw samples each shard retains for
+// percentile estimation; min/max/sum/count are tracked separately so memory
+// use stays flat regardless of how long a run goes.
+const ringShardSize = 4096
+
+// latencyShard is a fixed-size ring buffer of recent samples plus exact
+// running min/max/sum/count, all updated with atomics so it can be written
+// from multiple goroutines without a lock.
+type latencyShard struct {
+	ring  [ringShardSize]int64
+	next  uint64
+	count uint64
+	min   int64
+	max   int64
+	sum   int64
+}
+
+func newLatencyShard() *latencyShard {
+	return &latencyShard{min: math.MaxInt64, max: math.MinInt64}
+}
+
+func (s *latencyShard) add(d time.Duration) {
+	n := int64(d)
+
+	i := atomic.AddUint64(&s.next, 1) - 1
+	atomic.StoreInt64(&s.ring[i%ringShardSize], n)
+	atomic.AddUint64(&s.count, 1)
+	atomic.AddInt64(&s.sum, n)
+
+	for {
+		cur := atomic.LoadInt64(&s.min)
+		if n >= cur || atomic.CompareAndSwapInt64(&s.min, cur, n) {
+			break
+		}
+	}
+	for {
+		cur := atomic.LoadInt64(&s.max)
+		if n <= cur || atomic.CompareAndSwapInt64(&s.max, cur, n) {
+			break
+		}
+	}
+}
+
+// samples returns up to ringShardSize of the most recently recorded
+// samples, for percentile estimation.
+func (s *latencyShard) samples() []time.Duration {
+	n := atomic.LoadUint64(&s.count)
+	if n > ringShardSize {
+		n = ringShardSize
+	}
+
+	out := make([]time.Duration, n)
+	for i := uint64(0); i < n; i++ {
+		out[i] = time.Duration(atomic.LoadInt64(&s.ring[i]))
+	}
+	return out
+}
+
+// latencyTracker shards latency recording by publisher id so concurrent
+// recorders don't contend on the same shard.
+type latencyTracker struct {
+	shards [ringShardCount]*latencyShard
+}
+
+func newLatencyTracker() *latencyTracker {
+	t := &latencyTracker{}
+	for i := range t.shards {
+		t.shards[i] = newLatencyShard()
+	}
+	return t
+}
+
+func (t *latencyTracker) record(publisherID uint32, d time.Duration) {
+	t.shards[publisherID%ringShardCount].add(d)
+}
+
+// merge combines all shards into exact min/max/avg/count plus a bounded
+// sample set suitable for percentile estimation.
+func (t *latencyTracker) merge() (min, max, avg time.Duration, count uint64, samples []time.Duration) {
+	min = time.Duration(math.MaxInt64)
+	max = time.Duration(math.MinInt64)
+	var sum int64
+
+	for _, s := range t.shards {
+		c := atomic.LoadUint64(&s.count)
+		if c == 0 {
+			continue
+		}
+		count += c
+		sum += atomic.LoadInt64(&s.sum)
+
+		if m := time.Duration(atomic.LoadInt64(&s.min)); m < min {
+			min = m
+		}
+		if m := time.Duration(atomic.LoadInt64(&s.max)); m > max {
+			max = m
+		}
+
+		samples = append(samples, s.samples()...)
+	}
+
+	if count == 0 {
+		return 0, 0, 0, 0, nil
+	}
+
+	avg = time.Duration(sum / int64(count))
+	return min, max, avg, count, samples
+}
@@ -0,0 +1,51 @@
+package mqttbench
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyTrackerMerge(t *testing.T) {
+	tracker := newLatencyTracker()
+	for i := 0; i < 100; i++ {
+		tracker.record(uint32(i), time.Duration(i+1)*time.Millisecond)
+	}
+
+	min, max, avg, count, samples := tracker.merge()
+	if min != time.Millisecond {
+		t.Errorf("min = %v, want %v", min, time.Millisecond)
+	}
+	if max != 100*time.Millisecond {
+		t.Errorf("max = %v, want %v", max, 100*time.Millisecond)
+	}
+	if count != 100 {
+		t.Errorf("count = %d, want 100", count)
+	}
+	if avg != 50*time.Millisecond+500*time.Microsecond {
+		t.Errorf("avg = %v, want %v", avg, 50*time.Millisecond+500*time.Microsecond)
+	}
+	if len(samples) != 100 {
+		t.Errorf("len(samples) = %d, want 100", len(samples))
+	}
+}
+
+func TestLatencyTrackerMergeEmpty(t *testing.T) {
+	min, max, avg, count, samples := newLatencyTracker().merge()
+	if min != 0 || max != 0 || avg != 0 || count != 0 || samples != nil {
+		t.Errorf("merge on empty tracker = (%v, %v, %v, %d, %v), want all zero", min, max, avg, count, samples)
+	}
+}
+
+func TestLatencyShardBoundedMemory(t *testing.T) {
+	shard := newLatencyShard()
+	for i := 0; i < ringShardSize*2; i++ {
+		shard.add(time.Duration(i) * time.Millisecond)
+	}
+
+	if got := len(shard.samples()); got != ringShardSize {
+		t.Errorf("len(samples()) = %d, want %d", got, ringShardSize)
+	}
+	if shard.max != int64((ringShardSize*2-1)*int(time.Millisecond)) {
+		t.Errorf("max = %d, want the last recorded sample", shard.max)
+	}
+}
@@ -0,0 +1,156 @@
+package mqttbench
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// headerSize is the publisher-id + sequence + send-time correlation header
+// at the front of every payload. The rest of PayloadSize is filler.
+const headerSize = 16
+
+// Scenario describes a configurable load profile for a single Target run.
+type Scenario struct {
+	// Publishers is the number of independent publisher goroutines to run
+	// concurrently against the target. Defaults to 1.
+	Publishers int
+
+	// ClientsPerPublisher, when non-zero, has each publisher open this
+	// many of its own paho.Client connections and round-robin its
+	// messages across them, instead of sharing the Target's single pub
+	// client.
+	ClientsPerPublisher int
+
+	// Rate is the number of messages/sec each publisher attempts to send.
+	// A Rate of 0 disables rate limiting (publish as fast as possible).
+	Rate float64
+
+	// Jitter replaces the fixed inter-message period implied by Rate with
+	// a Poisson-distributed (exponential interarrival) delay of the same
+	// mean.
+	Jitter bool
+
+	// Duration, combined with Rate, determines how many messages each
+	// publisher sends (Rate * Duration in seconds). It is not enforced as a
+	// wall-clock limit, and with a Rate of 0 it yields no messages. Ignored
+	// if Messages is set.
+	Duration time.Duration
+
+	// Messages is the number of messages each publisher sends. Takes
+	// precedence over Duration.
+	Messages uint
+
+	// PayloadSize is the total size in bytes of each published message.
+	// Values below headerSize are rounded up.
+	PayloadSize int
+
+	// Topics lists the topics to fan out publishes across. A topic
+	// containing "%d" is expanded per-publisher, e.g. "bench/%d" with 4
+	// publishers fans out to bench/0..bench/3. Defaults to
+	// []string{"scox/bench"}.
+	Topics []string
+
+	// LossTimeout bounds how long runTarget waits, after all publishers
+	// have finished sending, for outstanding messages to arrive before
+	// giving up on them as lost. Defaults to 5 seconds.
+	LossTimeout time.Duration
+}
+
+// DefaultScenario is the original benchmark's behavior: a single publisher
+// sending `times` messages at roughly one every 10ms.
+func DefaultScenario(times uint) Scenario {
+	return Scenario{
+		Publishers:  1,
+		Rate:        100, // one message per 10ms
+		Messages:    times,
+		PayloadSize: headerSize,
+		Topics:      []string{"scox/bench"},
+	}
+}
+
+// scenarioTopics returns s.Topics, falling back to the original hardcoded
+// topic if none were configured.
+func scenarioTopics(s Scenario) []string {
+	if len(s.Topics) == 0 {
+		return []string{"scox/bench"}
+	}
+	return s.Topics
+}
+
+// expandTopics substitutes publisherID into any "%d" fan-out pattern in
+// topics, leaving topics without the pattern untouched.
+func expandTopics(topics []string, publisherID int) []string {
+	expanded := make([]string, len(topics))
+	for i, topic := range topics {
+		if strings.Contains(topic, "%d") {
+			expanded[i] = fmt.Sprintf(topic, publisherID)
+		} else {
+			expanded[i] = topic
+		}
+	}
+	return expanded
+}
+
+// messagesPerPublisher returns how many messages a single publisher should
+// send for the scenario: Messages directly if set, otherwise derived from
+// Rate*Duration.
+func messagesPerPublisher(s Scenario) uint {
+	if s.Messages > 0 {
+		return s.Messages
+	}
+	if s.Duration > 0 && s.Rate > 0 {
+		return uint(s.Rate * s.Duration.Seconds())
+	}
+	return 0
+}
+
+// lossTimeout returns s.LossTimeout, falling back to a 5 second default.
+func lossTimeout(s Scenario) time.Duration {
+	if s.LossTimeout > 0 {
+		return s.LossTimeout
+	}
+	return 5 * time.Second
+}
+
+// interMessageDelay returns how long a publisher should wait between
+// messages for the scenario's Rate, applying Poisson jitter if requested.
+func interMessageDelay(s Scenario) time.Duration {
+	if s.Rate <= 0 {
+		return 0
+	}
+
+	period := time.Duration(float64(time.Second) / s.Rate)
+	if s.Jitter {
+		period = time.Duration(rand.ExpFloat64() * float64(period))
+	}
+	return period
+}
+
+// buildPayload constructs a message payload carrying a correlation header
+// (publisher id, sequence number, send time) padded out to size bytes.
+func buildPayload(publisherID, seq uint32, size int) []byte {
+	if size < headerSize {
+		size = headerSize
+	}
+
+	buf := make([]byte, size)
+	binary.BigEndian.PutUint32(buf[0:4], publisherID)
+	binary.BigEndian.PutUint32(buf[4:8], seq)
+	binary.BigEndian.PutUint64(buf[8:16], uint64(time.Now().UnixNano()))
+	return buf
+}
+
+// parsePayload extracts the correlation header written by buildPayload.
+func parsePayload(payload []byte) (publisherID, seq uint32, sentAt time.Time, err error) {
+	if len(payload) < headerSize {
+		return 0, 0, time.Time{}, fmt.Errorf("payload too short to contain header: %d bytes", len(payload))
+	}
+
+	publisherID = binary.BigEndian.Uint32(payload[0:4])
+	seq = binary.BigEndian.Uint32(payload[4:8])
+	nanos := int64(binary.BigEndian.Uint64(payload[8:16]))
+	return publisherID, seq, time.Unix(0, nanos), nil
+}
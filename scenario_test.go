@@ -0,0 +1,58 @@
+package mqttbench
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMessagesPerPublisher(t *testing.T) {
+	cases := []struct {
+		name string
+		s    Scenario
+		want uint
+	}{
+		{"messages set", Scenario{Messages: 50, Rate: 10, Duration: time.Second}, 50},
+		{"derived from rate and duration", Scenario{Rate: 20, Duration: 2 * time.Second}, 40},
+		{"neither set", Scenario{}, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := messagesPerPublisher(c.s); got != c.want {
+				t.Errorf("messagesPerPublisher(%+v) = %d, want %d", c.s, got, c.want)
+			}
+		})
+	}
+}
+
+func TestExpandTopics(t *testing.T) {
+	got := expandTopics([]string{"bench/%d", "fixed"}, 3)
+	want := []string{"bench/3", "fixed"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("expandTopics = %v, want %v", got, want)
+	}
+}
+
+func TestBuildAndParsePayloadRoundTrip(t *testing.T) {
+	payload := buildPayload(7, 42, 64)
+	if len(payload) != 64 {
+		t.Fatalf("len(payload) = %d, want 64", len(payload))
+	}
+
+	publisherID, seq, sentAt, err := parsePayload(payload)
+	if err != nil {
+		t.Fatalf("parsePayload: %v", err)
+	}
+	if publisherID != 7 || seq != 42 {
+		t.Errorf("parsePayload = (%d, %d), want (7, 42)", publisherID, seq)
+	}
+	if time.Since(sentAt) < 0 || time.Since(sentAt) > time.Second {
+		t.Errorf("parsePayload sentAt = %v, not close to now", sentAt)
+	}
+}
+
+func TestParsePayloadTooShort(t *testing.T) {
+	if _, _, _, err := parsePayload(make([]byte, headerSize-1)); err == nil {
+		t.Error("parsePayload on a too-short payload: want error, got nil")
+	}
+}
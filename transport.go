@@ -0,0 +1,162 @@
+package mqttbench
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/quic-go/quic-go"
+)
+
+// Transport selects the network transport a Target's pub/sub connections
+// use to reach the broker.
+type Transport string
+
+const (
+	TransportTCP  Transport = "tcp"
+	TransportTLS  Transport = "tls"
+	TransportWS   Transport = "ws"
+	TransportWSS  Transport = "wss"
+	TransportQUIC Transport = "quic"
+)
+
+// TransportOptions configures the non-default transports. TLSConfig is used
+// for "tls" and "wss" (and as the basis of QUIC's TLS config). WSPath is the
+// HTTP path the WS/WSS upgrade request is made against, defaulting to
+// "/mqtt". ALPNProtocols sets the TLS ALPN protocol list advertised during
+// the handshake.
+type TransportOptions struct {
+	TLSConfig     *tls.Config
+	WSPath        string
+	ALPNProtocols []string
+}
+
+// dial opens a net.Conn to addr using the given transport.
+func dial(transport Transport, addr string, opts TransportOptions) (net.Conn, error) {
+	switch transport {
+	case "", TransportTCP:
+		return net.Dial("tcp", addr)
+	case TransportTLS:
+		return tls.Dial("tcp", addr, tlsConfigWithALPN(opts))
+	case TransportWS, TransportWSS:
+		return dialWebsocket(transport, addr, opts)
+	case TransportQUIC:
+		return dialQUIC(addr, opts)
+	default:
+		return nil, fmt.Errorf("unsupported transport: %q", transport)
+	}
+}
+
+func tlsConfigWithALPN(opts TransportOptions) *tls.Config {
+	cfg := opts.TLSConfig
+	if cfg == nil {
+		cfg = &tls.Config{}
+	} else {
+		cfg = cfg.Clone()
+	}
+	if len(opts.ALPNProtocols) > 0 {
+		cfg.NextProtos = opts.ALPNProtocols
+	}
+	return cfg
+}
+
+func dialWebsocket(transport Transport, addr string, opts TransportOptions) (net.Conn, error) {
+	path := opts.WSPath
+	if path == "" {
+		path = "/mqtt"
+	}
+
+	scheme := "ws"
+	dialer := websocket.Dialer{Subprotocols: []string{"mqtt"}}
+	if transport == TransportWSS {
+		scheme = "wss"
+		dialer.TLSClientConfig = tlsConfigWithALPN(opts)
+	}
+
+	u := url.URL{Scheme: scheme, Host: addr, Path: path}
+
+	conn, _, err := dialer.Dial(u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wsConn{Conn: conn}, nil
+}
+
+// wsConn adapts a *websocket.Conn, which exchanges discrete framed
+// messages, to the net.Conn byte-stream interface paho.Client expects.
+type wsConn struct {
+	*websocket.Conn
+	reader io.Reader
+}
+
+func (w *wsConn) Read(b []byte) (int, error) {
+	for w.reader == nil {
+		_, r, err := w.Conn.NextReader()
+		if err != nil {
+			return 0, err
+		}
+		w.reader = r
+	}
+
+	n, err := w.reader.Read(b)
+	if err == io.EOF {
+		w.reader = nil
+		err = nil
+	}
+	return n, err
+}
+
+func (w *wsConn) Write(b []byte) (int, error) {
+	if err := w.Conn.WriteMessage(websocket.BinaryMessage, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (w *wsConn) SetDeadline(t time.Time) error {
+	if err := w.Conn.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return w.Conn.SetWriteDeadline(t)
+}
+
+func dialQUIC(addr string, opts TransportOptions) (net.Conn, error) {
+	cfg := tlsConfigWithALPN(opts)
+	if len(cfg.NextProtos) == 0 {
+		cfg.NextProtos = []string{"mqtt"}
+	}
+
+	conn, err := quic.DialAddr(context.Background(), addr, cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := conn.OpenStreamSync(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return &quicConn{connection: conn, Stream: stream}, nil
+}
+
+// quicConn adapts a single QUIC stream to net.Conn.
+type quicConn struct {
+	connection quic.Connection
+	quic.Stream
+}
+
+func (q *quicConn) LocalAddr() net.Addr  { return q.connection.LocalAddr() }
+func (q *quicConn) RemoteAddr() net.Addr { return q.connection.RemoteAddr() }
+
+func (q *quicConn) SetDeadline(t time.Time) error {
+	if err := q.Stream.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return q.Stream.SetWriteDeadline(t)
+}
@@ -0,0 +1,9 @@
+package mqttbench
+
+import "testing"
+
+func TestDialUnsupportedTransport(t *testing.T) {
+	if _, err := dial(Transport("carrier-pigeon"), "localhost:1883", TransportOptions{}); err == nil {
+		t.Error("dial with an unsupported transport = nil error, want one")
+	}
+}